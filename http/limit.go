@@ -0,0 +1,132 @@
+package http
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrResponseTooLarge is returned when a response body exceeds the configured limit
+var ErrResponseTooLarge = errors.New("response body exceeds size limit")
+
+type limitedTransport struct {
+	inner    http.RoundTripper
+	maxBytes int64
+}
+
+func (lt limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := lt.inner.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.Reader = resp.Body
+	closer := io.Closer(resp.Body)
+
+	// The stdlib transport only decodes gzip when it asked for it itself, so a
+	// caller setting Accept-Encoding would otherwise get the raw stream and
+	// bypass the limit on the decompressed size.
+	if hasBody(req, resp) && strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(resp.Body)
+		switch {
+		case err == io.EOF:
+			// an empty body, there is nothing to decompress
+		case err != nil:
+			_ = resp.Body.Close()
+			return nil, fmt.Errorf("limited transport: invalid gzip body: %w", err)
+		default:
+			body = gz
+			closer = multiCloser{gz, resp.Body}
+		}
+
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+	} else if lt.maxBytes > 0 && hasBody(req, resp) && resp.ContentLength > lt.maxBytes {
+		_ = resp.Body.Close()
+		return nil, ErrResponseTooLarge
+	}
+
+	if lt.maxBytes <= 0 {
+		resp.Body = readCloser{body, closer}
+		return resp, nil
+	}
+
+	resp.Body = &limitedBody{r: body, closer: closer, remaining: lt.maxBytes}
+	return resp, nil
+}
+
+// LimitedRoundtripper caps the size of response bodies at maxBytes. Responses that
+// announce a larger Content-Length fail immediately, otherwise reading past the
+// limit returns ErrResponseTooLarge. Gzip encoded bodies are decompressed and the
+// limit applies to the decompressed size, which protects against decompression bombs.
+// A maxBytes of zero or less disables the limit, gzip bodies are still decompressed.
+func LimitedRoundtripper(trans http.RoundTripper, maxBytes int64) http.RoundTripper {
+	if trans == nil {
+		trans = http.DefaultTransport
+	}
+
+	return &limitedTransport{
+		inner:    trans,
+		maxBytes: maxBytes,
+	}
+}
+
+// hasBody reports if the response can carry a body, HEAD, 204 and 304 responses
+// keep the Content-Encoding of the resource without sending any bytes
+func hasBody(req *http.Request, resp *http.Response) bool {
+	if req.Method == http.MethodHead || resp.ContentLength == 0 {
+		return false
+	}
+	return resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotModified
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type limitedBody struct {
+	r         io.Reader
+	closer    io.Closer
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// anything left in the stream means the body is over the limit
+		var probe [1]byte
+		n, err := b.r.Read(probe[:])
+		if n > 0 {
+			return 0, ErrResponseTooLarge
+		}
+		return 0, err
+	}
+
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.r.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.closer.Close()
+}
+
+type multiCloser []io.Closer
+
+func (m multiCloser) Close() error {
+	var first error
+	for _, c := range m {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,139 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitedRoundtripper(t *testing.T) {
+	payload := bytes.Repeat([]byte("a"), 1024)
+
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	_, err := gw.Write(payload)
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gzip":
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(gzipped.Bytes())
+		case "/gzip/empty":
+			// flushing before any write forces a chunked response with no data
+			w.Header().Set("Content-Encoding", "gzip")
+			w.(http.Flusher).Flush()
+		case "/gzip/no-content":
+			w.Header().Set("Content-Encoding", "gzip")
+			w.WriteHeader(http.StatusNoContent)
+		case "/gzip/not-modified":
+			w.Header().Set("Content-Encoding", "gzip")
+			w.WriteHeader(http.StatusNotModified)
+		case "/chunked":
+			w.Write(payload[:512])
+			w.(http.Flusher).Flush()
+			w.Write(payload[512:])
+		default:
+			w.Write(payload)
+		}
+	}))
+	defer ts.Close()
+
+	do := func(t *testing.T, method string, limit int64, path string, acceptGzip bool) ([]byte, error) {
+		client := &http.Client{Transport: LimitedRoundtripper(nil, limit)}
+		req, err := http.NewRequest(method, ts.URL+path, nil)
+		require.NoError(t, err)
+		if acceptGzip {
+			req.Header.Set("Accept-Encoding", "gzip")
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		return ioutil.ReadAll(resp.Body)
+	}
+	get := func(t *testing.T, limit int64, path string, acceptGzip bool) ([]byte, error) {
+		return do(t, http.MethodGet, limit, path, acceptGzip)
+	}
+
+	t.Run("within limit", func(t *testing.T) {
+		body, err := get(t, 1024, "/", false)
+		require.NoError(t, err)
+		assert.Equal(t, payload, body)
+	})
+
+	t.Run("content length over limit", func(t *testing.T) {
+		_, err := get(t, 100, "/", false)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrResponseTooLarge))
+	})
+
+	t.Run("streamed body over limit", func(t *testing.T) {
+		_, err := get(t, 100, "/chunked", false)
+		assert.Equal(t, ErrResponseTooLarge, err)
+	})
+
+	t.Run("gzip decompressed", func(t *testing.T) {
+		body, err := get(t, 1024, "/gzip", true)
+		require.NoError(t, err)
+		assert.Equal(t, payload, body)
+	})
+
+	t.Run("gzip over decompressed limit", func(t *testing.T) {
+		require.Less(t, gzipped.Len(), 100)
+		_, err := get(t, 100, "/gzip", true)
+		assert.Equal(t, ErrResponseTooLarge, err)
+	})
+
+	t.Run("transport decompressed gzip over limit", func(t *testing.T) {
+		_, err := get(t, 100, "/gzip", false)
+		assert.Equal(t, ErrResponseTooLarge, err)
+	})
+
+	t.Run("gzip without body", func(t *testing.T) {
+		for _, tc := range []struct {
+			name   string
+			method string
+			path   string
+		}{
+			{"head", http.MethodHead, "/gzip"},
+			{"no content", http.MethodGet, "/gzip/no-content"},
+			{"not modified", http.MethodGet, "/gzip/not-modified"},
+			{"empty chunked body", http.MethodGet, "/gzip/empty"},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				body, err := do(t, tc.method, 100, tc.path, true)
+				require.NoError(t, err)
+				assert.Empty(t, body)
+			})
+		}
+	})
+
+	t.Run("head over limit", func(t *testing.T) {
+		// HEAD reports the Content-Length of the resource without sending it
+		body, err := do(t, http.MethodHead, 100, "/", false)
+		require.NoError(t, err)
+		assert.Empty(t, body)
+	})
+
+	t.Run("no limit", func(t *testing.T) {
+		for _, limit := range []int64{0, -1} {
+			body, err := get(t, limit, "/", false)
+			require.NoError(t, err)
+			assert.Equal(t, payload, body)
+
+			body, err = get(t, limit, "/gzip", true)
+			require.NoError(t, err)
+			assert.Equal(t, payload, body)
+		}
+	})
+}
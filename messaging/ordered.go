@@ -0,0 +1,108 @@
+package messaging
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/nats-io/stan.go"
+)
+
+// OrderedHandler processes messages with the same key one at a time and in the
+// order they were received, while messages with different keys are processed in
+// parallel. Keys are hashed onto a fixed number of workers, each with its own queue.
+//
+// The subscription must use stan.SetManualAckMode and the handler must Ack each
+// message once it is processed. With automatic acks stan acks a message as soon as
+// Handle queues it, so a crash loses everything still waiting in the queues.
+//
+// Ordering only holds as long as messages aren't redelivered. A message that isn't
+// acked within the subscription's AckWait is redelivered behind any later messages
+// with the same key, so AckWait has to cover the time spent queued as well as the
+// time spent processing. Handlers that can't tolerate this should check
+// msg.Redelivered.
+type OrderedHandler struct {
+	key     func(*stan.Msg) string
+	handler stan.MsgHandler
+	queues  []chan *stan.Msg
+	done    chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+}
+
+// NewOrderedHandler starts the workers. queueSize is the number of messages each worker
+// will buffer before Handle blocks, which applies backpressure to the subscription.
+func NewOrderedHandler(workers, queueSize int, key func(*stan.Msg) string, handler stan.MsgHandler) *OrderedHandler {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	h := &OrderedHandler{
+		key:     key,
+		handler: handler,
+		queues:  make([]chan *stan.Msg, workers),
+		done:    make(chan struct{}),
+	}
+	for i := range h.queues {
+		q := make(chan *stan.Msg, queueSize)
+		h.queues[i] = q
+		h.wg.Add(1)
+		go h.work(q)
+	}
+	return h
+}
+
+func (h *OrderedHandler) work(q chan *stan.Msg) {
+	defer h.wg.Done()
+	for {
+		select {
+		case msg := <-q:
+			h.handler(msg)
+		case <-h.done:
+			// finish what was queued before Close
+			for {
+				select {
+				case msg := <-q:
+					h.handler(msg)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// Handle queues the message on the worker that owns its key, it can be passed
+// directly to Subscribe or QueueSubscribe. Calls made after Close return without
+// queueing, and calls blocked on a full queue are released. A call racing with Close
+// may still queue its message after the worker finished draining, so it is never
+// processed. Such messages are left unacked and the server redelivers them.
+func (h *OrderedHandler) Handle(msg *stan.Msg) {
+	select {
+	case <-h.done:
+		return
+	default:
+	}
+
+	select {
+	case h.queues[h.worker(h.key(msg))] <- msg:
+	case <-h.done:
+	}
+}
+
+func (h *OrderedHandler) worker(key string) int {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(key))
+	return int(hash.Sum32() % uint32(len(h.queues)))
+}
+
+// Close stops accepting messages and waits for the ones already queued to be processed.
+// It is safe to call while Handle is still being called.
+func (h *OrderedHandler) Close() {
+	h.once.Do(func() {
+		close(h.done)
+	})
+	h.wg.Wait()
+}
@@ -0,0 +1,145 @@
+package messaging
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/stan.go"
+	"github.com/nats-io/stan.go/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderedHandler(t *testing.T) {
+	keyFn := func(msg *stan.Msg) string {
+		return msg.Subject
+	}
+	makeMsg := func(key string, seq uint64) *stan.Msg {
+		return &stan.Msg{MsgProto: pb.MsgProto{Subject: key, Sequence: seq}}
+	}
+
+	t.Run("ordered per key", func(t *testing.T) {
+		var mtx sync.Mutex
+		seen := make(map[string][]uint64)
+
+		h := NewOrderedHandler(4, 10, keyFn, func(msg *stan.Msg) {
+			mtx.Lock()
+			seen[msg.Subject] = append(seen[msg.Subject], msg.Sequence)
+			mtx.Unlock()
+		})
+
+		for seq := uint64(1); seq <= 100; seq++ {
+			h.Handle(makeMsg(fmt.Sprintf("key-%d", seq%5), seq))
+		}
+		h.Close()
+
+		total := 0
+		for key, seqs := range seen {
+			total += len(seqs)
+			for i := 1; i < len(seqs); i++ {
+				assert.Less(t, seqs[i-1], seqs[i], "messages for %s out of order", key)
+			}
+		}
+		assert.Equal(t, 100, total)
+	})
+
+	t.Run("parallel across keys", func(t *testing.T) {
+		block := make(chan struct{})
+		done := make(chan uint64, 1)
+
+		h := NewOrderedHandler(2, 1, keyFn, func(msg *stan.Msg) {
+			if msg.Sequence == 1 {
+				<-block
+				return
+			}
+			done <- msg.Sequence
+		})
+
+		// find a key that lands on the other worker than the blocked one
+		h.Handle(makeMsg("a", 1))
+		var other string
+		for i := 0; other == ""; i++ {
+			k := fmt.Sprintf("b-%d", i)
+			if h.worker(k) != h.worker("a") {
+				other = k
+			}
+		}
+		h.Handle(makeMsg(other, 2))
+
+		select {
+		case seq := <-done:
+			assert.Equal(t, uint64(2), seq)
+		case <-time.After(time.Second):
+			require.Fail(t, "message on a different key was blocked")
+		}
+		close(block)
+		h.Close()
+	})
+
+	t.Run("close while handle is blocked", func(t *testing.T) {
+		block := make(chan struct{})
+		var mtx sync.Mutex
+		var processed []uint64
+
+		h := NewOrderedHandler(1, 0, keyFn, func(msg *stan.Msg) {
+			if msg.Sequence == 1 {
+				<-block
+			}
+			mtx.Lock()
+			processed = append(processed, msg.Sequence)
+			mtx.Unlock()
+		})
+
+		// the only worker is stuck on the first message, so the next Handle blocks
+		h.Handle(makeMsg("a", 1))
+		handled := make(chan struct{})
+		go func() {
+			h.Handle(makeMsg("a", 2))
+			close(handled)
+		}()
+
+		select {
+		case <-handled:
+			require.Fail(t, "Handle should block while the queue is full")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		closed := make(chan struct{})
+		go func() {
+			h.Close()
+			close(closed)
+		}()
+
+		select {
+		case <-handled:
+		case <-time.After(time.Second):
+			require.Fail(t, "Handle stayed blocked after Close")
+		}
+
+		close(block)
+		select {
+		case <-closed:
+		case <-time.After(time.Second):
+			require.Fail(t, "Close didn't return")
+		}
+
+		// calls after Close are dropped instead of panicking
+		h.Handle(makeMsg("a", 3))
+		h.Close()
+
+		mtx.Lock()
+		defer mtx.Unlock()
+		assert.Equal(t, uint64(1), processed[0])
+		assert.NotContains(t, processed, uint64(3))
+	})
+
+	t.Run("negative sizes are clamped", func(t *testing.T) {
+		var count int
+		h := NewOrderedHandler(-1, -1, keyFn, func(*stan.Msg) { count++ })
+		h.Handle(makeMsg("a", 1))
+		h.Close()
+		assert.Equal(t, 1, count)
+	})
+}
@@ -0,0 +1,166 @@
+package messaging
+
+import (
+	"context"
+	"time"
+
+	"github.com/nats-io/stan.go"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultReplayIdleTimeout = 5 * time.Second
+
+// ReplayConfig describes the range of a NATS streaming channel to reprocess. The start
+// is either From or FromSequence, exactly one of them must be set. The end bounds are
+// inclusive and optional, when both are set the replay stops at whichever comes first,
+// when neither is set it runs until the channel goes idle. stan sends From to the server
+// as an offset from the time of subscribing, use FromSequence for an exact start.
+type ReplayConfig struct {
+	Subject string
+
+	From         time.Time
+	FromSequence uint64
+	To           time.Time
+	ToSequence   uint64
+
+	// IdleTimeout is how long to wait for the next message before considering
+	// the channel drained (default 5s, used for zero or negative values)
+	IdleTimeout time.Duration
+
+	// DryRun will only log the messages that would be replayed
+	DryRun bool
+}
+
+// ReplayHandler processes a single replayed message, an error stops the replay
+type ReplayHandler func(msg *stan.Msg) error
+
+// Republish builds a ReplayHandler that publishes each message to subject
+func Republish(conn NatsWriter, subject string) ReplayHandler {
+	return func(msg *stan.Msg) error {
+		return conn.Publish(subject, msg.Data)
+	}
+}
+
+// Replay will re-consume the messages on the configured subject within the range,
+// one at a time and in order, and hand them to the handler. It blocks until a message
+// past the end arrives, the channel is idle for IdleTimeout, the handler fails or ctx is done.
+// It returns the number of messages replayed.
+func Replay(ctx context.Context, conn stan.Conn, config ReplayConfig, handler ReplayHandler, log logrus.FieldLogger) (int, error) {
+	if log == nil {
+		log = silent
+	}
+	if config.Subject == "" {
+		return 0, errors.New("Must provide a subject to replay")
+	}
+	if config.From.IsZero() == (config.FromSequence == 0) {
+		return 0, errors.New("Must provide exactly one of a start time or a start sequence")
+	}
+	if !config.To.IsZero() && config.To.Before(config.From) {
+		return 0, errors.New("Replay end time is before the start time")
+	}
+	if config.ToSequence != 0 && config.ToSequence < config.FromSequence {
+		return 0, errors.New("Replay end sequence is before the start sequence")
+	}
+	if handler == nil && !config.DryRun {
+		return 0, errors.New("Must provide a handler unless doing a dry run")
+	}
+
+	idle := config.IdleTimeout
+	if idle <= 0 {
+		idle = defaultReplayIdleTimeout
+	}
+
+	log = log.WithFields(logrus.Fields{
+		"subject": config.Subject,
+		"dry_run": config.DryRun,
+	})
+
+	var start stan.SubscriptionOption
+	if config.FromSequence != 0 {
+		start = stan.StartAtSequence(config.FromSequence)
+		log = log.WithField("from_sequence", config.FromSequence)
+	} else {
+		start = stan.StartAtTime(config.From)
+		log = log.WithField("from", config.From.Format(time.RFC3339))
+	}
+	if !config.To.IsZero() {
+		log = log.WithField("to", config.To.Format(time.RFC3339))
+	}
+	if config.ToSequence != 0 {
+		log = log.WithField("to_sequence", config.ToSequence)
+	}
+
+	msgs := make(chan *stan.Msg)
+	done := make(chan struct{})
+
+	sub, err := conn.Subscribe(config.Subject, func(msg *stan.Msg) {
+		select {
+		case msgs <- msg:
+		case <-done:
+		}
+	}, start, stan.SetManualAckMode(), stan.MaxInflight(1))
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to subscribe for replay")
+	}
+	defer func() {
+		// release a callback blocked on msgs before unsubscribing
+		close(done)
+		if err := sub.Unsubscribe(); err != nil {
+			log.WithError(err).Warn("Failed to unsubscribe after replay")
+		}
+	}()
+
+	log.Info("Starting replay")
+
+	timer := time.NewTimer(idle)
+	defer timer.Stop()
+
+	count := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return count, ctx.Err()
+		case <-timer.C:
+			log.WithField("replayed", count).Info("No more messages to replay")
+			return count, nil
+		case msg := <-msgs:
+			ts := time.Unix(0, msg.Timestamp)
+			if (!config.To.IsZero() && ts.After(config.To)) || (config.ToSequence != 0 && msg.Sequence > config.ToSequence) {
+				log.WithField("replayed", count).Info("Reached the end of the replay range")
+				return count, nil
+			}
+
+			l := log.WithFields(logrus.Fields{
+				"sequence":  msg.Sequence,
+				"timestamp": ts.Format(time.RFC3339Nano),
+			})
+			if config.DryRun {
+				l.Info("Would replay message")
+			} else if err := handler(msg); err != nil {
+				return count, errors.Wrapf(err, "Failed to replay message %d", msg.Sequence)
+			} else {
+				l.Debug("Replayed message")
+			}
+			count++
+
+			if err := msg.Ack(); err != nil {
+				return count, errors.Wrapf(err, "Failed to ack message %d", msg.Sequence)
+			}
+
+			// don't wait out the idle timeout when the end is the last message
+			if config.ToSequence != 0 && msg.Sequence == config.ToSequence {
+				log.WithField("replayed", count).Info("Reached the end of the replay range")
+				return count, nil
+			}
+
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(idle)
+		}
+	}
+}
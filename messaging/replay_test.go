@@ -0,0 +1,197 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	natsd "github.com/nats-io/nats-streaming-server/server"
+	"github.com/nats-io/stan.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayValidation(t *testing.T) {
+	now := time.Now()
+	noop := func(*stan.Msg) error { return nil }
+
+	tests := []struct {
+		name    string
+		config  ReplayConfig
+		handler ReplayHandler
+	}{
+		{"no subject", ReplayConfig{From: now}, noop},
+		{"no start", ReplayConfig{Subject: "s"}, noop},
+		{"both starts", ReplayConfig{Subject: "s", From: now, FromSequence: 1}, noop},
+		{"end time before start", ReplayConfig{Subject: "s", From: now, To: now.Add(-time.Second)}, noop},
+		{"end sequence before start", ReplayConfig{Subject: "s", FromSequence: 5, ToSequence: 4}, noop},
+		{"no handler", ReplayConfig{Subject: "s", From: now}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := Replay(context.Background(), nil, tt.config, tt.handler, nil)
+			assert.Error(t, err)
+			assert.Equal(t, 0, count)
+		})
+	}
+}
+
+func TestReplay(t *testing.T) {
+	conn := runStreamingServer(t)
+
+	// stan sends the start time as a delta from now, leave a gap so the
+	// first message isn't lost to the latency of subscribing
+	start := time.Now()
+	time.Sleep(50 * time.Millisecond)
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, conn.Publish("replay", []byte(fmt.Sprintf("msg-%d", i))))
+	}
+	time.Sleep(10 * time.Millisecond)
+	mid := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	for i := 4; i <= 5; i++ {
+		require.NoError(t, conn.Publish("replay", []byte(fmt.Sprintf("msg-%d", i))))
+	}
+
+	replay := func(t *testing.T, config ReplayConfig) ([]uint64, int, error) {
+		if config.Subject == "" {
+			config.Subject = "replay"
+		}
+		config.IdleTimeout = 100 * time.Millisecond
+		var seen []uint64
+		count, err := Replay(context.Background(), conn, config, func(msg *stan.Msg) error {
+			seen = append(seen, msg.Sequence)
+			return nil
+		}, nil)
+		return seen, count, err
+	}
+
+	t.Run("from time until idle", func(t *testing.T) {
+		seen, count, err := replay(t, ReplayConfig{From: start})
+		require.NoError(t, err)
+		assert.Equal(t, 5, count)
+		assert.Equal(t, []uint64{1, 2, 3, 4, 5}, seen)
+	})
+
+	t.Run("time range", func(t *testing.T) {
+		seen, count, err := replay(t, ReplayConfig{From: start, To: mid})
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+		assert.Equal(t, []uint64{1, 2, 3}, seen)
+	})
+
+	t.Run("sequence range", func(t *testing.T) {
+		seen, count, err := replay(t, ReplayConfig{FromSequence: 2, ToSequence: 4})
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+		assert.Equal(t, []uint64{2, 3, 4}, seen)
+	})
+
+	t.Run("sequence range ending on the last message", func(t *testing.T) {
+		var seen []uint64
+		begin := time.Now()
+		count, err := Replay(context.Background(), conn, ReplayConfig{
+			Subject:      "replay",
+			FromSequence: 4,
+			ToSequence:   5,
+			IdleTimeout:  time.Minute,
+		}, func(msg *stan.Msg) error {
+			seen = append(seen, msg.Sequence)
+			return nil
+		}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+		assert.Equal(t, []uint64{4, 5}, seen)
+		assert.Less(t, int64(time.Since(begin)), int64(time.Second), "replay waited for the idle timeout")
+	})
+
+	t.Run("negative idle timeout uses the default", func(t *testing.T) {
+		count, err := Replay(context.Background(), conn, ReplayConfig{
+			Subject:      "replay",
+			FromSequence: 1,
+			ToSequence:   5,
+			IdleTimeout:  -time.Second,
+		}, func(*stan.Msg) error { return nil }, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 5, count)
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		seen, count, err := replay(t, ReplayConfig{FromSequence: 1, DryRun: true})
+		require.NoError(t, err)
+		assert.Equal(t, 5, count)
+		assert.Empty(t, seen)
+	})
+
+	t.Run("handler error", func(t *testing.T) {
+		boom := errors.New("boom")
+		count, err := Replay(context.Background(), conn, ReplayConfig{Subject: "replay", FromSequence: 1}, func(msg *stan.Msg) error {
+			if msg.Sequence == 2 {
+				return boom
+			}
+			return nil
+		}, nil)
+		assert.True(t, errors.Is(err, boom))
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("republish", func(t *testing.T) {
+		count, err := Replay(context.Background(), conn, ReplayConfig{
+			Subject:      "replay",
+			FromSequence: 4,
+			IdleTimeout:  100 * time.Millisecond,
+		}, Republish(conn, "replay.copy"), nil)
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+
+		var data []string
+		_, err = Replay(context.Background(), conn, ReplayConfig{
+			Subject:      "replay.copy",
+			FromSequence: 1,
+			IdleTimeout:  100 * time.Millisecond,
+		}, func(msg *stan.Msg) error {
+			data = append(data, string(msg.Data))
+			return nil
+		}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"msg-4", "msg-5"}, data)
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		count, err := Replay(ctx, conn, ReplayConfig{
+			Subject:     "replay.empty",
+			From:        start,
+			IdleTimeout: time.Minute,
+		}, func(*stan.Msg) error { return nil }, nil)
+		assert.Equal(t, context.DeadlineExceeded, err)
+		assert.Equal(t, 0, count)
+	})
+}
+
+func runStreamingServer(t *testing.T) stan.Conn {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+
+	sOpts := natsd.GetDefaultOptions()
+	sOpts.ID = "replay-test"
+	nOpts := natsd.DefaultNatsServerOptions
+	nOpts.Host = "127.0.0.1"
+	nOpts.Port = port
+
+	srv, err := natsd.RunServerWithOpts(sOpts, &nOpts)
+	require.NoError(t, err)
+	t.Cleanup(srv.Shutdown)
+
+	conn, err := stan.Connect(sOpts.ID, "replay-client", stan.NatsURL(fmt.Sprintf("nats://127.0.0.1:%d", port)))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
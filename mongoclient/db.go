@@ -34,6 +34,7 @@ type Config struct {
 	ConnTimeout        time.Duration
 	Auth               *Auth
 	SecondaryPreferred bool
+	EnableTracing      bool
 }
 
 func ConnectWithOptions(log logrus.FieldLogger, replSet string, servers []string, opts ...Option) (*mongo.Client, error) {
@@ -84,6 +85,9 @@ func Connect(log logrus.FieldLogger, config *Config) (*mongo.Client, error) {
 	if config.SecondaryPreferred {
 		opts = append(opts, SecondaryPreferred())
 	}
+	if config.EnableTracing {
+		opts = append(opts, TracingOption())
+	}
 
 	return ConnectWithOptions(log, config.ReplSetName, config.Servers, opts...)
 }
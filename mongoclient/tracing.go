@@ -0,0 +1,133 @@
+package mongoclient
+
+import (
+	"context"
+	"sync"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TracingOption emits a span for every command run against the database. Spans are
+// only created as children of a span already in the operation's context, so commands
+// issued outside of a traced request don't produce orphaned traces.
+//
+// A CommandMonitor set by an earlier option keeps receiving every event. The driver
+// only holds one monitor though, so one set after this option replaces the tracing
+// monitor; apply TracingOption last.
+func TracingOption() Option {
+	return func(opts *options.ClientOptions) error {
+		opts.SetMonitor(chainMonitors(newTracingMonitor(), opts.Monitor))
+		return nil
+	}
+}
+
+// chainMonitors calls first and then next for every event, next may be nil
+func chainMonitors(first, next *event.CommandMonitor) *event.CommandMonitor {
+	if next == nil {
+		return first
+	}
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			if first.Started != nil {
+				first.Started(ctx, evt)
+			}
+			if next.Started != nil {
+				next.Started(ctx, evt)
+			}
+		},
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			if first.Succeeded != nil {
+				first.Succeeded(ctx, evt)
+			}
+			if next.Succeeded != nil {
+				next.Succeeded(ctx, evt)
+			}
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			if first.Failed != nil {
+				first.Failed(ctx, evt)
+			}
+			if next.Failed != nil {
+				next.Failed(ctx, evt)
+			}
+		},
+	}
+}
+
+type spanKey struct {
+	connectionID string
+	requestID    int64
+}
+
+type tracingMonitor struct {
+	spans map[spanKey]opentracing.Span
+	mtx   sync.Mutex
+}
+
+func newTracingMonitor() *event.CommandMonitor {
+	m := &tracingMonitor{
+		spans: make(map[spanKey]opentracing.Span),
+	}
+	return &event.CommandMonitor{
+		Started:   m.started,
+		Succeeded: m.succeeded,
+		Failed:    m.failed,
+	}
+}
+
+func (m *tracingMonitor) started(ctx context.Context, evt *event.CommandStartedEvent) {
+	parent := opentracing.SpanFromContext(ctx)
+	if parent == nil {
+		return
+	}
+
+	// use the parent's tracer so spans follow whatever tracer the request was traced
+	// with, even when it is set up after connecting
+	span := parent.Tracer().StartSpan("mongo."+evt.CommandName, opentracing.ChildOf(parent.Context()))
+	ext.SpanKindRPCClient.Set(span)
+	ext.Component.Set(span, "mongoclient")
+	ext.DBType.Set(span, "mongo")
+	ext.DBInstance.Set(span, evt.DatabaseName)
+	span.SetTag("db.operation", evt.CommandName)
+
+	// the first element of a command document is the command name and its target collection
+	if elem, err := evt.Command.IndexErr(0); err == nil {
+		if coll, ok := elem.Value().StringValueOK(); ok {
+			span.SetTag("db.collection", coll)
+		}
+	}
+
+	m.mtx.Lock()
+	m.spans[spanKey{evt.ConnectionID, evt.RequestID}] = span
+	m.mtx.Unlock()
+}
+
+func (m *tracingMonitor) succeeded(ctx context.Context, evt *event.CommandSucceededEvent) {
+	if span := m.finished(evt.CommandFinishedEvent); span != nil {
+		span.Finish()
+	}
+}
+
+func (m *tracingMonitor) failed(ctx context.Context, evt *event.CommandFailedEvent) {
+	if span := m.finished(evt.CommandFinishedEvent); span != nil {
+		ext.Error.Set(span, true)
+		span.SetTag("error.msg", evt.Failure)
+		span.Finish()
+	}
+}
+
+func (m *tracingMonitor) finished(evt event.CommandFinishedEvent) opentracing.Span {
+	key := spanKey{evt.ConnectionID, evt.RequestID}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	span, ok := m.spans[key]
+	if !ok {
+		return nil
+	}
+	delete(m.spans, key)
+	return span
+}
@@ -0,0 +1,105 @@
+package mongoclient
+
+import (
+	"context"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestTracingMonitor(t *testing.T) {
+	tracer := mocktracer.New()
+	monitor := newTracingMonitor()
+
+	cmd, err := bson.Marshal(bson.D{{Key: "find", Value: "sites"}})
+	require.NoError(t, err)
+
+	start := func(ctx context.Context, requestID int64) {
+		monitor.Started(ctx, &event.CommandStartedEvent{
+			Command:      cmd,
+			DatabaseName: "netlify",
+			CommandName:  "find",
+			RequestID:    requestID,
+			ConnectionID: "conn-1",
+		})
+	}
+	finished := func(requestID int64) event.CommandFinishedEvent {
+		return event.CommandFinishedEvent{CommandName: "find", RequestID: requestID, ConnectionID: "conn-1"}
+	}
+
+	parent := tracer.StartSpan("request")
+	ctx := opentracing.ContextWithSpan(context.Background(), parent)
+
+	t.Run("no parent span", func(t *testing.T) {
+		start(context.Background(), 1)
+		monitor.Succeeded(context.Background(), &event.CommandSucceededEvent{CommandFinishedEvent: finished(1)})
+		assert.Empty(t, tracer.FinishedSpans())
+	})
+
+	t.Run("succeeded", func(t *testing.T) {
+		tracer.Reset()
+		start(ctx, 2)
+		monitor.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: finished(2)})
+
+		spans := tracer.FinishedSpans()
+		require.Len(t, spans, 1)
+		span := spans[0]
+		assert.Equal(t, "mongo.find", span.OperationName)
+		assert.Equal(t, parent.Context().(mocktracer.MockSpanContext).SpanID, span.ParentID)
+		assert.Equal(t, "sites", span.Tag("db.collection"))
+		assert.Equal(t, "find", span.Tag("db.operation"))
+		assert.Equal(t, "netlify", span.Tag("db.instance"))
+		assert.Nil(t, span.Tag("error"))
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		tracer.Reset()
+		start(ctx, 3)
+		monitor.Failed(ctx, &event.CommandFailedEvent{CommandFinishedEvent: finished(3), Failure: "boom"})
+
+		spans := tracer.FinishedSpans()
+		require.Len(t, spans, 1)
+		assert.Equal(t, true, spans[0].Tag("error"))
+		assert.Equal(t, "boom", spans[0].Tag("error.msg"))
+	})
+}
+
+func TestTracingOptionChainsMonitor(t *testing.T) {
+	var started, succeeded int
+	existing := &event.CommandMonitor{
+		Started:   func(context.Context, *event.CommandStartedEvent) { started++ },
+		Succeeded: func(context.Context, *event.CommandSucceededEvent) { succeeded++ },
+	}
+
+	opts := options.Client().SetMonitor(existing)
+	require.NoError(t, TracingOption()(opts))
+	require.NotNil(t, opts.Monitor)
+
+	// the tracer is only known after the option was applied, like a service
+	// that sets up tracing after connecting
+	tracer := mocktracer.New()
+	ctx := opentracing.ContextWithSpan(context.Background(), tracer.StartSpan("request"))
+
+	cmd, err := bson.Marshal(bson.D{{Key: "find", Value: "sites"}})
+	require.NoError(t, err)
+	finished := event.CommandFinishedEvent{CommandName: "find", RequestID: 1, ConnectionID: "conn-1"}
+
+	opts.Monitor.Started(ctx, &event.CommandStartedEvent{Command: cmd, CommandName: "find", RequestID: 1, ConnectionID: "conn-1"})
+	opts.Monitor.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: finished})
+	// the existing monitor has no Failed callback, that must not panic
+	opts.Monitor.Failed(ctx, &event.CommandFailedEvent{})
+
+	assert.Equal(t, 1, started)
+	assert.Equal(t, 1, succeeded)
+
+	spans := tracer.FinishedSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "mongo.find", spans[0].OperationName)
+	assert.Equal(t, "sites", spans[0].Tag("db.collection"))
+}